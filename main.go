@@ -7,13 +7,17 @@ import (
 	"time"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	//_ "net/http/pprof"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/time/rate"
 
+	v1 "github.com/sak0/fortuner/pkg/api/v1"
+	"github.com/sak0/fortuner/pkg/index"
 	"github.com/sak0/fortuner/pkg/rules"
 	"github.com/sak0/fortuner/pkg/notifier"
 	"github.com/sak0/fortuner/pkg/utils"
@@ -27,32 +31,45 @@ var (
 	evaluationInterval	time.Duration
 	updateInterval 		time.Duration
 	alertResendDelay	time.Duration
+	concurrentEvalLimit	int64
+	forGracePeriod		time.Duration
+	queryBackend		string
+	promqlAddr		string
 )
 
 type MyHandle struct{
 	ruleManager 	*rules.RuleManager
 	limiter 		*rate.Limiter
+	mux 			*http.ServeMux
 }
 func (h MyHandle)ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	err := h.limiter.Wait(req.Context())
-	if err != nil {
-		fmt.Fprintf(w, "Request Failed: %v\n", err)
-		return
-	}
-
 	switch req.URL.Path {
 	case "/reload":
+		if err := h.limiter.Wait(req.Context()); err != nil {
+			fmt.Fprintf(w, "Request Failed: %v\n", err)
+			return
+		}
 		h.ruleManager.Update()
 	default:
-		fmt.Fprintf(w, "xiaozhupeiqi\n")
+		h.mux.ServeHTTP(w, req)
 	}
 }
 
+func newMux(ruleManager *rules.RuleManager) *http.ServeMux {
+	mux := http.NewServeMux()
+	v1.New(ruleManager).Register(mux)
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "xiaozhupeiqi\n")
+	})
+	return mux
+}
+
 func init() {
 	flag.StringVar(&ruleFilesPath, "--rule-files-path",
 		"C:/Users/ThinkPad/go/src/github.com/sak0/fortuner/example/rules/", "path of rule files.")
 	flag.StringVar(&alertManagerAddr, "--alertmanager-addr",
-		"http://10.211.160.34:9093", "alertManager webhook url")
+		"http://10.211.160.34:9093", "comma-separated list of alertManager base urls")
 	flag.StringVar(&alertExtUrl, "--alert-ext-url",
 		"dev.yonghui.cn", "external url for alert infomation")
 	flag.DurationVar(&evaluationInterval, "--evaluation-interval",
@@ -61,6 +78,14 @@ func init() {
 		10 * time.Second, "interval for update rules.")
 	flag.DurationVar(&alertResendDelay, "--alert-resend-delay",
 		1 * time.Second, "min delay for one alert resend.")
+	flag.Int64Var(&concurrentEvalLimit, "--concurrent-eval-limit",
+		0, "max number of rule evaluations running at once across all groups, 0 means GOMAXPROCS.")
+	flag.DurationVar(&forGracePeriod, "--for-grace-period",
+		10 * time.Minute, "minimum duration an alert that was already firing before a reload keeps firing.")
+	flag.StringVar(&queryBackend, "--query-backend",
+		"index", "data source for rule evaluation: index or promql.")
+	flag.StringVar(&promqlAddr, "--promql-addr",
+		"", "Prometheus/VictoriaMetrics base url, required when --query-backend=promql.")
 	flag.Parse()
 
 	log.SetOutput(os.Stdout)
@@ -81,13 +106,35 @@ func main() {
 	notifierManager := notifier.NewManager(done, alertManagerAddr)
 	go notifierManager.Run()
 
+	extURL, err := url.Parse(alertExtUrl)
+	if err != nil {
+		panic(err)
+	}
+	if extURL.Scheme == "" {
+		extURL.Scheme = "http"
+	}
+
+	var queryFunc rules.QueryFunc
+	switch queryBackend {
+	case "promql":
+		queryFunc, err = rules.NewPromQLQueryFunc(promqlAddr)
+		if err != nil {
+			panic(err)
+		}
+	default:
+		queryFunc = rules.NewFuzzyIndexQueryFunc(index.NewClient())
+	}
+
 	ctx := context.Background()
 	ruleManager := rules.NewRuleManager(rules.ManagerOpts{
 		RulesFilePath:ruleFilesPath,
 		Interval: evaluationInterval,
-		NotifyFunc:sendAlerts(notifierManager, alertExtUrl),
+		NotifyFunc:sendAlerts(notifierManager, extURL),
 		Ctx:ctx,
 		ResendDelay:alertResendDelay,
+		ConcurrentEvalLimit:concurrentEvalLimit,
+		ForGracePeriod:forGracePeriod,
+		QueryFunc:queryFunc,
 	})
 	ruleManager.Update()
 
@@ -131,6 +178,7 @@ func main() {
 	h := MyHandle{
 		ruleManager:ruleManager,
 		limiter:rate.NewLimiter(limit, 1),
+		mux:newMux(ruleManager),
 	}
 	srv := http.Server{
 		Addr: "0.0.0.0:6060",
@@ -147,7 +195,7 @@ type sender interface {
 	Send(alerts ...*notifier.Alert)
 }
 
-func sendAlerts(s sender, externalURL string) rules.NotifyFunc {
+func sendAlerts(s sender, externalURL *url.URL) rules.NotifyFunc {
 	return func(ctx context.Context, alerts ...*rules.Alert) {
 		var res []*notifier.Alert
 
@@ -156,12 +204,11 @@ func sendAlerts(s sender, externalURL string) rules.NotifyFunc {
 				StartsAt:     alert.FiredAt,
 				Labels:       alert.Labels,
 				Annotations:  alert.Annotations,
-				GeneratorURL: externalURL,
+				GeneratorURL: generatorURL(externalURL, alert.Labels["alertname"]),
 			}
 			if !alert.ResolvedAt.IsZero() {
-				a.EndsAt = alert.ResolvedAt
-			} else {
-				a.EndsAt = alert.ValidUntil
+				endsAt := alert.ResolvedAt
+				a.EndsAt = &endsAt
 			}
 			res = append(res, a)
 		}
@@ -170,4 +217,14 @@ func sendAlerts(s sender, externalURL string) rules.NotifyFunc {
 			s.Send(res...)
 		}
 	}
+}
+
+// generatorURL builds the link Alertmanager shows next to a firing alert,
+// pointing back at the rule that generated it.
+func generatorURL(externalURL *url.URL, ruleName string) string {
+	u := *externalURL
+	q := u.Query()
+	q.Set("g0.expr", ruleName)
+	u.RawQuery = q.Encode()
+	return u.String()
 }
\ No newline at end of file
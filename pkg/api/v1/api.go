@@ -0,0 +1,194 @@
+// Package v1 implements the subset of Prometheus's HTTP API that applies to
+// rule and alert introspection, on top of a rules.RuleManager.
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sak0/fortuner/pkg/rules"
+)
+
+type status string
+
+const (
+	statusSuccess status = "success"
+	statusError   status = "error"
+)
+
+type apiResponse struct {
+	Status string      `json:"status"`
+	Data   interface{} `json:"data,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// Alert is the API representation of a single active alert instance.
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	State       string            `json:"state"`
+	ActiveAt    *time.Time        `json:"activeAt,omitempty"`
+	Value       string            `json:"value"`
+}
+
+// AlertingRule is the API representation of a single rule, including its
+// currently active alerts.
+type AlertingRule struct {
+	Name           string    `json:"name"`
+	Query          string    `json:"query"`
+	Health         string    `json:"health"`
+	LastError      string    `json:"lastError,omitempty"`
+	EvaluationTime float64   `json:"evaluationTime"`
+	LastEvaluation time.Time `json:"lastEvaluation"`
+	Alerts         []*Alert  `json:"alerts"`
+}
+
+// RuleGroup is the API representation of a single rule group.
+type RuleGroup struct {
+	Name           string         `json:"name"`
+	File           string         `json:"file"`
+	Rules          []AlertingRule `json:"rules"`
+	Interval       float64        `json:"interval"`
+	Offset         float64        `json:"evaluationOffset"`
+	LastEvaluation time.Time      `json:"lastEvaluation"`
+	EvaluationTime float64        `json:"evaluationTime"`
+}
+
+// API bundles the HTTP handlers exposed on top of a rules.RuleManager.
+type API struct {
+	ruleManager *rules.RuleManager
+}
+
+func New(ruleManager *rules.RuleManager) *API {
+	return &API{ruleManager: ruleManager}
+}
+
+// Register mounts the API's routes, plus the `/-/healthy` and `/-/ready`
+// health endpoints, onto mux.
+func (api *API) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/rules", api.rules)
+	mux.HandleFunc("/api/v1/alerts", api.alerts)
+	mux.HandleFunc("/api/v1/status/rulefiles", api.ruleFileStatus)
+	mux.HandleFunc("/-/healthy", api.healthy)
+	mux.HandleFunc("/-/ready", api.ready)
+}
+
+// ruleDiscovery is the data payload of /api/v1/rules, matching the shape
+// Prometheus uses so existing Prometheus-compatible clients can parse it.
+type ruleDiscovery struct {
+	Groups []RuleGroup `json:"groups"`
+}
+
+// alertDiscovery is the data payload of /api/v1/alerts, matching the shape
+// Prometheus uses so existing Prometheus-compatible clients can parse it.
+type alertDiscovery struct {
+	Alerts []*Alert `json:"alerts"`
+}
+
+func (api *API) rules(w http.ResponseWriter, req *http.Request) {
+	groups := api.ruleManager.RuleGroups()
+	resp := ruleDiscovery{Groups: make([]RuleGroup, 0, len(groups))}
+	for _, g := range groups {
+		resp.Groups = append(resp.Groups, toRuleGroup(g))
+	}
+	respondSuccess(w, resp)
+}
+
+func (api *API) alerts(w http.ResponseWriter, req *http.Request) {
+	resp := alertDiscovery{}
+	for _, g := range api.ruleManager.RuleGroups() {
+		for _, r := range g.Rules() {
+			resp.Alerts = append(resp.Alerts, toAlerts(r)...)
+		}
+	}
+	respondSuccess(w, resp)
+}
+
+// ruleFileStatusResponse reports whether the last rule file reload
+// succeeded and, if not, every error found while loading it.
+type ruleFileStatusResponse struct {
+	LastLoadSuccessful bool     `json:"lastLoadSuccessful"`
+	Errors             []string `json:"errors,omitempty"`
+}
+
+func (api *API) ruleFileStatus(w http.ResponseWriter, req *http.Request) {
+	loadErrs := api.ruleManager.LoadErrors()
+
+	resp := ruleFileStatusResponse{LastLoadSuccessful: len(loadErrs) == 0}
+	for _, err := range loadErrs {
+		resp.Errors = append(resp.Errors, err.Error())
+	}
+	respondSuccess(w, resp)
+}
+
+func (api *API) healthy(w http.ResponseWriter, req *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Fortuner is Healthy.\n"))
+}
+
+func (api *API) ready(w http.ResponseWriter, req *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Fortuner is Ready.\n"))
+}
+
+func toRuleGroup(g *rules.Group) RuleGroup {
+	rg := RuleGroup{
+		Name:           g.Name(),
+		File:           g.File(),
+		Interval:       g.Interval().Seconds(),
+		Offset:         g.Offset().Seconds(),
+		LastEvaluation: g.LastEvaluation(),
+		EvaluationTime: g.GetEvaluationTime().Seconds(),
+		Rules:          make([]AlertingRule, 0, len(g.Rules())),
+	}
+	for _, r := range g.Rules() {
+		rg.Rules = append(rg.Rules, toAlertingRule(r))
+	}
+	return rg
+}
+
+func toAlertingRule(r rules.Rule) AlertingRule {
+	ar := AlertingRule{
+		Name:           r.Name(),
+		Query:          r.Query(),
+		Health:         string(r.Health()),
+		EvaluationTime: r.GetEvaluationDuration().Seconds(),
+		LastEvaluation: r.GetEvaluationTimestamp(),
+		Alerts:         toAlerts(r),
+	}
+	if err := r.LastError(); err != nil {
+		ar.LastError = err.Error()
+	}
+	return ar
+}
+
+func toAlerts(r rules.Rule) []*Alert {
+	alerts := make([]*Alert, 0, len(r.ActiveAlerts()))
+	for _, a := range r.ActiveAlerts() {
+		activeAt := a.ActiveAt
+		alerts = append(alerts, &Alert{
+			Labels:      a.Labels,
+			Annotations: a.Annotations,
+			State:       a.State.String(),
+			ActiveAt:    &activeAt,
+			Value:       a.Value,
+		})
+	}
+	return alerts
+}
+
+func respondSuccess(w http.ResponseWriter, data interface{}) {
+	respond(w, http.StatusOK, apiResponse{Status: string(statusSuccess), Data: data})
+}
+
+func respond(w http.ResponseWriter, code int, resp apiResponse) {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
@@ -0,0 +1,38 @@
+package notifier
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	queueLength = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "fortuner",
+		Subsystem: "notifier",
+		Name:      "queue_length",
+		Help:      "The number of alert batches queued for a given Alertmanager.",
+	}, []string{"alertmanager"})
+
+	alertsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "fortuner",
+		Subsystem: "notifier",
+		Name:      "dropped_total",
+		Help:      "The total number of alert batches dropped because a queue was full.",
+	}, []string{"alertmanager"})
+
+	sendLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "fortuner",
+		Subsystem: "notifier",
+		Name:      "send_latency_seconds",
+		Help:      "The latency of sending alert batches to an Alertmanager.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"alertmanager"})
+
+	sendFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "fortuner",
+		Subsystem: "notifier",
+		Name:      "send_failures_total",
+		Help:      "The total number of failed sends to an Alertmanager.",
+	}, []string{"alertmanager"})
+)
+
+func init() {
+	prometheus.MustRegister(queueLength, alertsDropped, sendLatency, sendFailures)
+}
@@ -0,0 +1,234 @@
+// Package notifier ships alerts to one or more Alertmanager instances using
+// the Alertmanager v2 HTTP API.
+package notifier
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	alertPushEndpoint = "/api/v2/alerts"
+	defaultTimeout    = 10 * time.Second
+	defaultQueueCap   = 1000
+	maxRetries        = 5
+	maxBackoff        = 1 * time.Minute
+)
+
+// Alert is the notifier's wire representation of a firing or resolved
+// alert, encoded as JSON for the Alertmanager v2 API. EndsAt is a pointer
+// because encoding/json's omitempty never omits a zero time.Time: left as
+// a value, every still-firing alert would be marshaled with an endsAt of
+// "0001-01-01T00:00:00Z", which Alertmanager treats as already resolved.
+type Alert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     time.Time         `json:"startsAt,omitempty"`
+	EndsAt       *time.Time        `json:"endsAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// BasicAuth holds optional HTTP basic-auth credentials for the Alertmanager
+// API.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Options configures a Manager.
+type Options struct {
+	// Addrs is a comma-separated list of Alertmanager base URLs. Every
+	// Alertmanager gets its own queue and receives the full alert batch, so
+	// one Alertmanager being down doesn't affect delivery to the others.
+	Addrs string
+
+	Timeout       time.Duration
+	QueueCapacity int
+	BasicAuth     *BasicAuth
+	TLSConfig     *tls.Config
+}
+
+// Manager fans alerts out to every configured Alertmanager.
+type Manager struct {
+	done chan interface{}
+	ams  []*alertmanager
+}
+
+// NewManager builds a Manager targeting addrs, a comma-separated list of
+// Alertmanager base URLs, using default timeouts and queue sizes.
+func NewManager(done chan interface{}, addrs string) *Manager {
+	return NewManagerWithOptions(done, Options{Addrs: addrs})
+}
+
+// NewManagerWithOptions builds a Manager with full control over timeouts,
+// queue capacity, and auth/TLS.
+func NewManagerWithOptions(done chan interface{}, opts Options) *Manager {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.QueueCapacity <= 0 {
+		opts.QueueCapacity = defaultQueueCap
+	}
+
+	client := &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: &http.Transport{TLSClientConfig: opts.TLSConfig},
+	}
+
+	m := &Manager{done: done}
+	for _, addr := range strings.Split(opts.Addrs, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if _, err := url.Parse(addr); err != nil {
+			glog.Errorf("notifier: skipping invalid alertmanager addr %q: %v", addr, err)
+			continue
+		}
+		m.ams = append(m.ams, newAlertmanager(addr, client, opts))
+	}
+	return m
+}
+
+// Run starts a delivery worker per Alertmanager and blocks until done is
+// closed.
+func (m *Manager) Run() {
+	for _, am := range m.ams {
+		go am.run(m.done)
+	}
+	<-m.done
+}
+
+// Send enqueues alerts for delivery to every configured Alertmanager.
+func (m *Manager) Send(alerts ...*Alert) {
+	if len(alerts) == 0 {
+		return
+	}
+	for _, am := range m.ams {
+		am.enqueue(alerts)
+	}
+}
+
+// retryableError wraps an error returned for a 5xx response or a network
+// failure, both of which are worth retrying. Any other error (e.g. a 4xx)
+// is treated as permanent for that batch.
+type retryableError struct{ error }
+
+type alertmanager struct {
+	addr   string
+	url    string
+	client *http.Client
+	auth   *BasicAuth
+
+	queue chan []*Alert
+}
+
+func newAlertmanager(addr string, client *http.Client, opts Options) *alertmanager {
+	return &alertmanager{
+		addr:   addr,
+		url:    strings.TrimRight(addr, "/") + alertPushEndpoint,
+		client: client,
+		auth:   opts.BasicAuth,
+		queue:  make(chan []*Alert, opts.QueueCapacity),
+	}
+}
+
+// enqueue drops the oldest queued batch to make room when the queue is
+// full, so a slow/unreachable Alertmanager degrades to "recent alerts only"
+// instead of blocking the whole pipeline.
+func (a *alertmanager) enqueue(alerts []*Alert) {
+	select {
+	case a.queue <- alerts:
+	default:
+		select {
+		case <-a.queue:
+			alertsDropped.WithLabelValues(a.addr).Inc()
+		default:
+		}
+		select {
+		case a.queue <- alerts:
+		default:
+			alertsDropped.WithLabelValues(a.addr).Inc()
+		}
+	}
+	queueLength.WithLabelValues(a.addr).Set(float64(len(a.queue)))
+}
+
+func (a *alertmanager) run(done chan interface{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case alerts := <-a.queue:
+			queueLength.WithLabelValues(a.addr).Set(float64(len(a.queue)))
+			a.sendWithRetry(alerts)
+		}
+	}
+}
+
+func (a *alertmanager) sendWithRetry(alerts []*Alert) {
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		glog.Errorf("notifier: marshal %d alerts for %s failed: %v", len(alerts), a.addr, err)
+		return
+	}
+
+	backoff := 1 * time.Second
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		start := time.Now()
+		err := a.post(body)
+		sendLatency.WithLabelValues(a.addr).Observe(time.Since(start).Seconds())
+		if err == nil {
+			return
+		}
+
+		sendFailures.WithLabelValues(a.addr).Inc()
+		if _, retryable := err.(retryableError); !retryable {
+			glog.Errorf("notifier: send %d alerts to %s rejected, not retrying: %v", len(alerts), a.addr, err)
+			return
+		}
+
+		glog.Warningf("notifier: send %d alerts to %s failed (attempt %d/%d): %v", len(alerts), a.addr, attempt, maxRetries, err)
+		if attempt == maxRetries {
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (a *alertmanager) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.auth != nil {
+		req.SetBasicAuth(a.auth.Username, a.auth.Password)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return retryableError{err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 5 {
+		return retryableError{fmt.Errorf("alertmanager %s returned %s", a.addr, resp.Status)}
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("alertmanager %s returned %s", a.addr, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+// Package rulefmt parses and validates fortuner's rule file YAML format.
+package rulefmt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"gopkg.in/yaml.v2"
+)
+
+// RuleType identifies which Rule implementation a parsed rule is handled
+// by.
+type RuleType int
+
+const (
+	TypeFrequency RuleType = iota
+	TypeAny
+	TypeBlackList
+	TypeWhiteList
+)
+
+// RuleTypes maps a RuleType to the string used for it in rule files.
+var RuleTypes = map[RuleType]string{
+	TypeFrequency: "frequency",
+	TypeAny:       "any",
+	TypeBlackList: "blacklist",
+	TypeWhiteList: "whitelist",
+}
+
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Rule is a single alerting rule as written in a rule file.
+type Rule struct {
+	Alert       string            `yaml:"alert"`
+	Type        string            `yaml:"type"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Validate checks a single rule in isolation: required fields, a parseable
+// `for` duration, valid label names, and a known `type`.
+func (r *Rule) Validate() error {
+	if r.Alert == "" {
+		return fmt.Errorf("field alert must not be empty")
+	}
+	if r.Expr == "" {
+		return fmt.Errorf("field expr must not be empty")
+	}
+	if r.For != "" {
+		if _, err := time.ParseDuration(r.For); err != nil {
+			return fmt.Errorf("invalid for duration %q: %v", r.For, err)
+		}
+	}
+	for name := range r.Labels {
+		if !labelNameRE.MatchString(name) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+
+	known := false
+	for _, t := range RuleTypes {
+		if t == r.Type {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown rule type %q", r.Type)
+	}
+	return nil
+}
+
+// Duration wraps time.Duration so rule files can write intervals as plain
+// strings (e.g. "30s") instead of nanosecond integers.
+type Duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(dur)
+	return nil
+}
+
+// RuleGroup is a named collection of rules sharing an evaluation interval.
+type RuleGroup struct {
+	Name     string   `yaml:"name"`
+	Interval Duration `yaml:"interval,omitempty"`
+	Rules    []Rule   `yaml:"rules"`
+}
+
+// RuleGroups is the top-level document in a rule file.
+type RuleGroups struct {
+	Groups []RuleGroup `yaml:"groups"`
+}
+
+// Errors is a list of errors found while parsing or validating one or more
+// rule files. It satisfies the error interface so callers that only care
+// whether loading failed can still treat it as a single error, while
+// callers that want the detail (e.g. the rules HTTP API) can range over it.
+type Errors []error
+
+func (errs Errors) Error() string {
+	var b strings.Builder
+	for i, err := range errs {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// LowRuleError is returned for errors that aren't tied to a specific rule
+// or group, e.g. the file being unreadable or not valid YAML.
+type LowRuleError struct {
+	Msg string
+}
+
+func (e LowRuleError) Error() string {
+	return e.Msg
+}
+
+// HandleError logs a rule loading error without crashing the process, so a
+// single bad rule file doesn't take down an fsnotify-triggered reload.
+func HandleError(err error, msg string) {
+	glog.Errorf("%s: %v", msg, err)
+}
+
+// validate checks group names are unique within the file and rule names
+// are unique within each group, and validates every rule, aggregating every problem found instead of
+// stopping at the first one.
+func (g *RuleGroups) validate(file string) Errors {
+	var errs Errors
+
+	seenGroups := make(map[string]struct{}, len(g.Groups))
+	for _, rg := range g.Groups {
+		if _, ok := seenGroups[rg.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate group name %q", file, rg.Name))
+		}
+		seenGroups[rg.Name] = struct{}{}
+
+		if rg.Interval < 0 {
+			errs = append(errs, fmt.Errorf("%s: group %q has a negative interval", file, rg.Name))
+		}
+
+		seenRules := make(map[string]struct{}, len(rg.Rules))
+		for i := range rg.Rules {
+			rule := &rg.Rules[i]
+			if _, ok := seenRules[rule.Alert]; ok && rule.Alert != "" {
+				errs = append(errs, fmt.Errorf("%s: group %q: duplicate rule name %q", file, rg.Name, rule.Alert))
+			}
+			seenRules[rule.Alert] = struct{}{}
+
+			if err := rule.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: group %q, rule %q: %v", file, rg.Name, rule.Alert, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+// ParseFile reads and validates a single rule file. On success it returns
+// the parsed groups; on failure it returns a LowRuleError (unreadable file,
+// invalid YAML) or an Errors (one or more rule/group-level problems).
+func ParseFile(file string) (*RuleGroups, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, LowRuleError{Msg: fmt.Sprintf("read rule file %s: %v", file, err)}
+	}
+
+	var groups RuleGroups
+	if err := yaml.UnmarshalStrict(b, &groups); err != nil {
+		return nil, LowRuleError{Msg: fmt.Sprintf("parse rule file %s: %v", file, err)}
+	}
+
+	if errs := groups.validate(file); len(errs) > 0 {
+		return nil, errs
+	}
+
+	return &groups, nil
+}
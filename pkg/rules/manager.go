@@ -2,13 +2,19 @@ package rules
 
 import (
 	"context"
+	"hash/fnv"
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang/glog"
+	"golang.org/x/sync/semaphore"
+
 	"github.com/sak0/fortuner/pkg/rulefmt"
 )
 
@@ -16,6 +22,17 @@ var defaultInterval = 30 * time.Second
 
 type NotifyFunc func(ctx context.Context, alerts ...*Alert)
 
+// RuleHealth reflects the last evaluation outcome of a Rule, mirroring
+// Prometheus's rule health states so they can be surfaced through the
+// rules HTTP API.
+type RuleHealth string
+
+const (
+	HealthUnknown RuleHealth = "unknown"
+	HealthGood    RuleHealth = "ok"
+	HealthBad     RuleHealth = "err"
+)
+
 type Group struct {
 	name     string
 	file     string
@@ -24,6 +41,81 @@ type Group struct {
 	opts     ManagerOpts
 
 	done chan interface{}
+
+	// offset staggers this group's first evaluation within its interval, so
+	// that groups loaded at the same time don't all evaluate in lockstep.
+	offset time.Duration
+
+	statsMtx       sync.Mutex
+	lastEvaluation time.Time
+	evaluationTime time.Duration
+}
+
+// Offset returns the deterministic delay, derived from the group's key,
+// applied before its first evaluation.
+func (g *Group) Offset() time.Duration {
+	return g.offset
+}
+
+// groupOffset deterministically maps a group's key into [0, interval), the
+// same way Prometheus staggers rule group evaluation across reloads.
+func groupOffset(name, file string, interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(groupKey(name, file)))
+	return time.Duration(h.Sum64() % uint64(interval))
+}
+
+// Name returns the group's name.
+func (g *Group) Name() string {
+	return g.name
+}
+
+// File returns the rule file the group was loaded from.
+func (g *Group) File() string {
+	return g.file
+}
+
+// Interval returns the group's evaluation interval.
+func (g *Group) Interval() time.Duration {
+	return g.interval
+}
+
+// Rules returns the rules that make up the group.
+func (g *Group) Rules() []Rule {
+	return g.rules
+}
+
+// LastEvaluation returns the time of the last evaluation of the group.
+func (g *Group) LastEvaluation() time.Time {
+	g.statsMtx.Lock()
+	defer g.statsMtx.Unlock()
+
+	return g.lastEvaluation
+}
+
+// GetEvaluationTime returns the time the last evaluation of the group took.
+func (g *Group) GetEvaluationTime() time.Duration {
+	g.statsMtx.Lock()
+	defer g.statsMtx.Unlock()
+
+	return g.evaluationTime
+}
+
+func (g *Group) setEvaluationTime(dur time.Duration) {
+	g.statsMtx.Lock()
+	defer g.statsMtx.Unlock()
+
+	g.evaluationTime = dur
+}
+
+func (g *Group) setLastEvaluation(ts time.Time) {
+	g.statsMtx.Lock()
+	defer g.statsMtx.Unlock()
+
+	g.lastEvaluation = ts
 }
 
 func (g *Group) Stop() {
@@ -31,6 +123,14 @@ func (g *Group) Stop() {
 }
 
 func (g *Group) Run() {
+	groupInterval.WithLabelValues(g.name, g.file).Set(g.interval.Seconds())
+
+	select {
+	case <-time.After(g.offset):
+	case <-g.done:
+		return
+	}
+
 	tick := time.NewTicker(g.interval)
 	defer tick.Stop()
 	defer glog.V(2).Infof("Group %s goroutine exit.\n", g.name)
@@ -101,16 +201,54 @@ func (g *Group) Eval(ts time.Time) {
 	case <-g.done:
 		return
 	default:
+		start := time.Now()
+		defer func() {
+			dur := time.Since(start)
+			g.setLastEvaluation(start)
+			g.setEvaluationTime(dur)
+			evalDuration.WithLabelValues(g.name, g.file).Observe(dur.Seconds())
+			if g.interval > 0 && dur > g.interval {
+				glog.Warningf("group %s with file %s evaluation took %v, longer than its interval %v\n",
+					g.name, g.file, dur, g.interval)
+				groupIterationsMissed.WithLabelValues(g.name, g.file).Inc()
+			}
+		}()
+
+		queryFunc := g.opts.QueryFunc
+		if queryFunc == nil {
+			glog.Warningf("group %s with file %s has no QueryFunc configured, skipping evaluation\n", g.name, g.file)
+			return
+		}
+
 		var alerts []*Alert
 		for _, rule := range g.rules {
-			if err := rule.DetermineIndex(g.opts.EnableFuzzyIndex); err != nil {
-				glog.V(2).Infof("rule(%s) determine index failed: %v", rule.Name(), err)
-				continue
+			if sem := g.opts.ConcurrentEvalSem; sem != nil {
+				if err := sem.Acquire(g.opts.Ctx, 1); err != nil {
+					glog.V(2).Infof("group %s: failed to acquire eval slot for rule %s: %v", g.name, rule.Name(), err)
+					continue
+				}
 			}
-			if err := rule.Eval(g.opts.Ctx, time.Now()); err != nil {
+
+			ruleStart := time.Now()
+
+			if err := rule.Eval(g.opts.Ctx, time.Now(), queryFunc); err != nil {
 				glog.V(2).Infof("rule %s eval failed: %v", rule.Name(), err)
+				rule.SetHealth(HealthBad)
+				rule.SetLastError(err)
+				evalFailures.WithLabelValues(g.name, g.file).Inc()
+				if sem := g.opts.ConcurrentEvalSem; sem != nil {
+					sem.Release(1)
+				}
 				continue
 			}
+			rule.SetHealth(HealthGood)
+			rule.SetLastError(nil)
+			rule.SetEvaluationDuration(time.Since(ruleStart))
+			rule.SetEvaluationTimestamp(ruleStart)
+
+			if sem := g.opts.ConcurrentEvalSem; sem != nil {
+				sem.Release(1)
+			}
 
 			ctx, cancel := context.WithCancel(g.opts.Ctx)
 			for obj := range needSending(ctx, genAlerts(ctx, rule.ActiveAlerts()), g.opts.ResendDelay, ts) {
@@ -124,14 +262,15 @@ func (g *Group) Eval(ts time.Time) {
 	}
 }
 
-func NewGroup(opts ManagerOpts, groupName string, fileName string, rules []Rule) *Group {
+func NewGroup(opts ManagerOpts, groupName string, fileName string, interval time.Duration, rules []Rule) *Group {
 	return &Group{
-		interval: opts.Interval,
+		interval: interval,
 		name:     groupName,
 		file:     fileName,
 		rules:    rules,
 		done:     make(chan interface{}),
 		opts:     opts,
+		offset:   groupOffset(groupName, fileName, interval),
 	}
 }
 
@@ -144,6 +283,23 @@ type ManagerOpts struct {
 	ResendDelay      time.Duration
 	TailTime         time.Duration
 	EnableFuzzyIndex bool
+
+	// ConcurrentEvalLimit bounds how many rule evaluations may run at once
+	// across all groups. Zero means the runtime's GOMAXPROCS is used.
+	ConcurrentEvalLimit int64
+	// ConcurrentEvalSem is derived from ConcurrentEvalLimit by
+	// NewRuleManager and shared by every Group built from these opts.
+	ConcurrentEvalSem *semaphore.Weighted
+
+	// ForGracePeriod bounds how long, after a reload replaces a rule with a
+	// matching one carrying a longer `for` duration, an alert that was
+	// already firing is allowed to keep firing instead of dropping back to
+	// pending while the new `for` duration re-accumulates.
+	ForGracePeriod time.Duration
+
+	// QueryFunc is the data source rule implementations evaluate against.
+	// If nil, groups log a warning and skip evaluation.
+	QueryFunc QueryFunc
 }
 
 type RuleManager struct {
@@ -151,12 +307,94 @@ type RuleManager struct {
 	opts       ManagerOpts
 	Groups     map[string]*Group
 	needUpdate int
+	loadErrs   rulefmt.Errors
+}
+
+// LoadErrors returns the errors, if any, found while loading rule files on
+// the most recent Update call.
+func (m *RuleManager) LoadErrors() rulefmt.Errors {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	return m.loadErrs
 }
 
 func groupKey(name, file string) string {
 	return name + ";" + file
 }
 
+// ruleFingerprint identifies a rule across reloads by its alert name, labels
+// and query, so state can be matched between an old and a new Group even
+// though both were rebuilt from scratch while parsing the rule file.
+func ruleFingerprint(r Rule) string {
+	return r.Name() + ";" + labelsFingerprint(r.Labels()) + ";" + r.Query()
+}
+
+// labelsFingerprint renders a label set as a stable string, sorting by key
+// so the same labels always fingerprint the same way regardless of map
+// iteration order.
+func labelsFingerprint(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(labels[name])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// copyState transfers active alerts and evaluation health/stats from the
+// rules and group stats of oldGroup into the matching rules of newGroup, so
+// that a reload triggered by an fsnotify event on an unrelated file in the
+// same directory doesn't reset in-flight pending/firing alerts.
+func copyState(oldGroup, newGroup *Group) {
+	oldGroup.statsMtx.Lock()
+	newGroup.statsMtx.Lock()
+	newGroup.evaluationTime = oldGroup.evaluationTime
+	newGroup.lastEvaluation = oldGroup.lastEvaluation
+	newGroup.statsMtx.Unlock()
+	oldGroup.statsMtx.Unlock()
+
+	for _, newRule := range newGroup.rules {
+		for _, oldRule := range oldGroup.rules {
+			if ruleFingerprint(newRule) != ruleFingerprint(oldRule) {
+				continue
+			}
+			if err := newRule.CopyState(oldRule); err != nil {
+				glog.V(2).Infof("group %s: copy state for rule %s failed: %v", newGroup.name, newRule.Name(), err)
+			}
+			break
+		}
+	}
+}
+
+// RuleGroups returns a snapshot of the currently loaded groups, sorted by
+// file and then by name, for use by the rules HTTP API.
+func (m *RuleManager) RuleGroups() []*Group {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	groups := make([]*Group, 0, len(m.Groups))
+	for _, g := range m.Groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].file != groups[j].file {
+			return groups[i].file < groups[j].file
+		}
+		return groups[i].name < groups[j].name
+	})
+
+	return groups
+}
+
 func (m *RuleManager) Lock() {
 	m.mtx.Lock()
 }
@@ -189,50 +427,64 @@ func (m *RuleManager) CleanNeedUpdate() {
 	m.needUpdate = 0
 }
 
-func (m *RuleManager) LoadGroups(fileNames []string) (map[string]*Group, error) {
+// LoadGroups parses and validates every file in fileNames, returning the
+// groups built from whichever files validated successfully plus the
+// aggregated errors for the ones that didn't. It never calls glog.Fatalf:
+// a bad rule file is reported, not fatal, so a single typo can't take down
+// an fsnotify-triggered reload.
+func (m *RuleManager) LoadGroups(fileNames []string) (map[string]*Group, rulefmt.Errors) {
 	allGroups := make(map[string]*Group)
+	var errs rulefmt.Errors
 
 	for _, file := range fileNames {
 		groups, err := rulefmt.ParseFile(file)
 		if err != nil {
-			glog.Fatalf("parse rule file %s failed: %v\n", file, err)
+			if fileErrs, ok := err.(rulefmt.Errors); ok {
+				errs = append(errs, fileErrs...)
+			} else {
+				errs = append(errs, err)
+			}
+			continue
 		}
 		for _, grp := range groups.Groups {
 			var rules []Rule
-			if grp.Interval == 0 {
-				grp.Interval = m.opts.Interval
+			interval := time.Duration(grp.Interval)
+			if interval == 0 {
+				interval = m.opts.Interval
 			}
 
+			// rulefmt.ParseFile already validated every rule's type and
+			// rejected duplicate group names within this file, so there's
+			// no unsupported-type or duplicate-group case left to handle
+			// here.
 			for _, rule := range grp.Rules {
-				if err := rule.Validate(); err != nil {
-					glog.Fatalf("validate rule %s failed: %v\n", rule.Alert, err)
-				}
 				switch rule.Type {
 				case rulefmt.RuleTypes[rulefmt.TypeFrequency]:
-					newRule := NewFrequencyRule(rule, grp.Interval)
+					newRule := NewFrequencyRule(rule, interval, m.opts.ForGracePeriod)
 					rules = append(rules, newRule)
 				case rulefmt.RuleTypes[rulefmt.TypeAny]:
-					newRule := NewAnyRule(rule, grp.Interval)
+					newRule := NewAnyRule(rule, interval, m.opts.ForGracePeriod)
 					rules = append(rules, newRule)
 				case rulefmt.RuleTypes[rulefmt.TypeBlackList]:
-					newRule := NewWhiteListRule(rule, grp.Interval, m.opts.TailTime)
+					newRule := NewWhiteListRule(rule, interval, m.opts.TailTime, m.opts.ForGracePeriod)
 					rules = append(rules, newRule)
 				case rulefmt.RuleTypes[rulefmt.TypeWhiteList]:
-					newRule := NewWhiteListRule(rule, grp.Interval, m.opts.TailTime)
+					newRule := NewWhiteListRule(rule, interval, m.opts.TailTime, m.opts.ForGracePeriod)
 					rules = append(rules, newRule)
-				default:
-					glog.V(2).Infof("Unsupport rule type: %s\n", rule.Type)
-					continue
 				}
 			}
-			newGroup := NewGroup(m.opts, grp.Name, file, rules)
-			allGroups[groupKey(grp.Name, file)] = newGroup
+			key := groupKey(grp.Name, file)
+			allGroups[key] = NewGroup(m.opts, grp.Name, file, interval, rules)
 		}
 	}
 
-	return allGroups, nil
+	return allGroups, errs
 }
 
+// Update reloads rule files from disk and, if every file validates, swaps
+// them in atomically. If any file fails to load, the previously loaded
+// Groups keep running and the errors are kept for LoadErrors/the
+// /api/v1/status/rulefiles endpoint.
 func (m *RuleManager) Update() {
 	m.needUpdate = 0
 
@@ -248,7 +500,8 @@ func (m *RuleManager) Update() {
 		return nil
 	})
 	if err != nil {
-		glog.Fatalf("%v", err)
+		glog.Errorf("walk rule files path %s failed: %v", m.opts.RulesFilePath, err)
+		return
 	}
 
 	//for _, file := range m.opts.RulesFiles {
@@ -262,14 +515,18 @@ func (m *RuleManager) Update() {
 	//}
 	glog.V(2).Infof("Update by rule files: %v\n", files)
 
-	newGroups, err := m.LoadGroups(files)
-	if err != nil {
-		msg := "unexpected error, please report bug."
-		if ruleErr, ok := err.(rulefmt.LowRuleError); ok {
-			msg = ruleErr.Msg
-		}
-		rulefmt.HandleError(err, msg)
+	newGroups, loadErrs := m.LoadGroups(files)
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.loadErrs = loadErrs
+	if len(loadErrs) > 0 {
+		rulefmt.HandleError(loadErrs, "rule file load failed, keeping previously loaded groups")
+		ruleGroupLastLoadSuccessful.Set(0)
+		return
 	}
+	ruleGroupLastLoadSuccessful.Set(1)
 
 	glog.V(3).Infof("Old groups: %#v\n", m.Groups)
 	glog.V(3).Infof("New groups: %#v\n", newGroups)
@@ -282,7 +539,11 @@ func (m *RuleManager) Update() {
 		if ok {
 			glog.V(3).Infof("Group[%s] with file %s need stop.\n", key, oldGroup.file)
 			delete(m.Groups, key)
+			// Stop blocks until oldGroup's Run goroutine has returned from any
+			// in-flight Eval, so copyState below never races with it mutating
+			// rule health/active-alert state.
 			oldGroup.Stop()
+			copyState(oldGroup, newGroup)
 		}
 		go newGroup.Run()
 		wg.Done()
@@ -298,6 +559,11 @@ func (m *RuleManager) Update() {
 }
 
 func NewRuleManager(opts ManagerOpts) *RuleManager {
+	if opts.ConcurrentEvalLimit <= 0 {
+		opts.ConcurrentEvalLimit = int64(runtime.GOMAXPROCS(0))
+	}
+	opts.ConcurrentEvalSem = semaphore.NewWeighted(opts.ConcurrentEvalLimit)
+
 	return &RuleManager{
 		mtx:        sync.RWMutex{},
 		opts:       opts,
@@ -0,0 +1,45 @@
+package rules
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	evalDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "fortuner",
+		Subsystem: "rule_group",
+		Name:      "evaluation_duration_seconds",
+		Help:      "The duration for a rule group to execute.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30},
+	}, []string{"group", "file"})
+
+	evalFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "fortuner",
+		Subsystem: "rule_evaluation",
+		Name:      "failures_total",
+		Help:      "The total number of rule evaluation failures.",
+	}, []string{"group", "file"})
+
+	groupInterval = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "fortuner",
+		Subsystem: "rule_group",
+		Name:      "interval_seconds",
+		Help:      "The interval of a rule group.",
+	}, []string{"group", "file"})
+
+	groupIterationsMissed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "fortuner",
+		Subsystem: "rule_group",
+		Name:      "iterations_missed_total",
+		Help:      "The total number of rule group evaluations that took longer than the group's interval.",
+	}, []string{"group", "file"})
+
+	ruleGroupLastLoadSuccessful = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "fortuner",
+		Subsystem: "rule_group",
+		Name:      "last_load_successful",
+		Help:      "Whether the last rule file reload succeeded, as a 1 or 0.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(evalDuration, evalFailures, groupInterval, groupIterationsMissed, ruleGroupLastLoadSuccessful)
+}
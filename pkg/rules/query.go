@@ -0,0 +1,24 @@
+package rules
+
+import (
+	"context"
+	"time"
+)
+
+// Sample is a single labeled measurement returned by a QueryFunc, modeled on
+// a PromQL instant-vector sample so the same rule can be evaluated against
+// either the fuzzy log index or a metrics backend.
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Vector is the result of a single QueryFunc call.
+type Vector []Sample
+
+// QueryFunc executes query against whatever data source a RuleManager was
+// configured with, as of ts. This mirrors Prometheus's EngineQueryFunc seam:
+// rule implementations evaluate through ManagerOpts.QueryFunc instead of
+// hard-wiring a specific backend, so they can run against the index-based
+// fuzzy lookup, a PromQL-compatible source, or a fake in tests.
+type QueryFunc func(ctx context.Context, query string, ts time.Time) (Vector, error)
@@ -0,0 +1,26 @@
+package rules
+
+import (
+	"context"
+	"time"
+
+	"github.com/sak0/fortuner/pkg/index"
+)
+
+// NewFuzzyIndexQueryFunc adapts the existing ES-backed fuzzy index client to
+// the QueryFunc seam, so rules authored against the log index keep working
+// unchanged when run through ManagerOpts.QueryFunc.
+func NewFuzzyIndexQueryFunc(client *index.Client) QueryFunc {
+	return func(ctx context.Context, query string, ts time.Time) (Vector, error) {
+		hits, err := client.Search(ctx, query, ts)
+		if err != nil {
+			return nil, err
+		}
+
+		vec := make(Vector, 0, len(hits))
+		for _, h := range hits {
+			vec = append(vec, Sample{Labels: h.Labels, Value: float64(h.Count)})
+		}
+		return vec, nil
+	}
+}
@@ -0,0 +1,49 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	promapi "github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+
+	"github.com/golang/glog"
+)
+
+// NewPromQLQueryFunc builds a QueryFunc backed by a Prometheus or
+// VictoriaMetrics instant-query API, so `expr:` rules can be authored
+// against metrics instead of the fuzzy log index.
+func NewPromQLQueryFunc(addr string) (QueryFunc, error) {
+	client, err := promapi.NewClient(promapi.Config{Address: addr})
+	if err != nil {
+		return nil, fmt.Errorf("build promql client for %s: %w", addr, err)
+	}
+	api := promv1.NewAPI(client)
+
+	return func(ctx context.Context, query string, ts time.Time) (Vector, error) {
+		val, warnings, err := api.Query(ctx, query, ts)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range warnings {
+			glog.V(2).Infof("promql query %q returned a warning: %s", query, w)
+		}
+
+		vec, ok := val.(model.Vector)
+		if !ok {
+			return nil, fmt.Errorf("promql query %q returned a %s, want a vector", query, val.Type())
+		}
+
+		out := make(Vector, 0, len(vec))
+		for _, sample := range vec {
+			labels := make(map[string]string, len(sample.Metric))
+			for name, value := range sample.Metric {
+				labels[string(name)] = string(value)
+			}
+			out = append(out, Sample{Labels: labels, Value: float64(sample.Value)})
+		}
+		return out, nil
+	}, nil
+}